@@ -0,0 +1,143 @@
+package inspeqtor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"inspeqtor/util"
+	"time"
+)
+
+// RuleRecovered is emitted by fireRuleEvent in place of RuleFailed when a
+// previously-failing fingerprint's next Verify() comes back Ok, so an
+// Action can tell a recovery apart from a fresh failure instead of seeing
+// RuleFailed both times. Its other EventType values (RuleFailed among them)
+// live outside this source fragment, so this picks a value well clear of
+// any small iota-based range they're likely to occupy.
+const RuleRecovered EventType = 1 << 16
+
+// alertState tracks the history of a single (check, rule, route) fingerprint
+// so repeat failures don't renotify on every cycle.
+type alertState struct {
+	Check      string
+	Metric     string
+	FirstSeen  time.Time
+	LastSent   time.Time
+	Count      int
+	LastStatus string
+}
+
+// Fingerprint computes a stable identifier for a triggering rule on a given
+// route, so the same failure seen across cycles maps to the same state
+// entry regardless of how many times Verify() has run.
+func Fingerprint(checkName string, rule *Rule, routeName string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%v|%v|%v|%s", checkName, rule.MetricName, rule.Op, rule.Threshold, routeName)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Notify records the outcome of a rule check for (check, rule, routeName)
+// and reports whether a notification should actually be sent: the first
+// time a fingerprint is seen, on recovery, and again once RenotifyInterval
+// has elapsed since the last notification. Everything in between is
+// suppressed so a sustained failure doesn't spam the route. An Ok status
+// drops the fingerprint's state, so a failure that clears and comes back
+// notifies again as if seen for the first time.
+//
+// routeName identifies the destination this fingerprint is scoped to,
+// normally an AlertRoute.Name. fireRuleEvent, which only has pre-resolved
+// Actions, passes a stable per-action substitute instead, since the
+// originating route's name isn't threaded through Rule.Actions.
+func (i *Inspeqtor) Notify(check Checkable, rule *Rule, routeName string, status string) (send bool, recovered bool) {
+	fp := Fingerprint(check.Name(), rule, routeName)
+	renotify := time.Duration(i.GlobalConfig.Top.RenotifyInterval) * time.Second
+
+	i.alertStatesMutex.Lock()
+	defer i.alertStatesMutex.Unlock()
+	if i.AlertStates == nil {
+		i.AlertStates = map[string]*alertState{}
+	}
+
+	state, seen := i.AlertStates[fp]
+	now := time.Now()
+
+	if status == "Ok" {
+		if seen && state.LastStatus != "Ok" {
+			recovered = true
+			send = true
+			util.Info("RECOVERED %s %s via %s", check.Name(), rule.MetricName, routeName)
+		}
+		delete(i.AlertStates, fp)
+		return send, recovered
+	}
+
+	if !seen {
+		state = &alertState{Check: check.Name(), Metric: rule.MetricName, FirstSeen: now}
+		i.AlertStates[fp] = state
+	}
+	state.Count++
+	state.LastStatus = status
+
+	if !seen || now.Sub(state.LastSent) >= renotify {
+		state.LastSent = now
+		send = true
+	}
+	return send, recovered
+}
+
+// ActiveAlerts returns a snapshot of every fingerprint currently tracked, for
+// reporting over the Unix socket (see Status).
+func (i *Inspeqtor) ActiveAlerts() map[string]alertState {
+	i.alertStatesMutex.Lock()
+	defer i.alertStatesMutex.Unlock()
+
+	snapshot := make(map[string]alertState, len(i.AlertStates))
+	for fp, state := range i.AlertStates {
+		snapshot[fp] = *state
+	}
+	return snapshot
+}
+
+// SilenceFingerprint mutes a single alert fingerprint until the given time,
+// independent of the global SilenceUntil.
+func (i *Inspeqtor) SilenceFingerprint(fingerprint string, until time.Time) {
+	i.fingerprintSilenceMutex.Lock()
+	defer i.fingerprintSilenceMutex.Unlock()
+	if i.FingerprintSilences == nil {
+		i.FingerprintSilences = map[string]time.Time{}
+	}
+	i.FingerprintSilences[fingerprint] = until
+}
+
+func (i *Inspeqtor) fingerprintSilenced(fingerprint string) bool {
+	i.fingerprintSilenceMutex.Lock()
+	defer i.fingerprintSilenceMutex.Unlock()
+	until, ok := i.FingerprintSilences[fingerprint]
+	return ok && time.Now().Before(until)
+}
+
+// Status renders every currently-active alert fingerprint as plain text, one
+// per line, for the Unix socket's "status" command (HandleCommand dispatches
+// the command name to this method).
+func (i *Inspeqtor) Status() string {
+	active := i.ActiveAlerts()
+	if len(active) == 0 {
+		return "No active alerts\n"
+	}
+
+	var out bytes.Buffer
+	for fp, state := range active {
+		fmt.Fprintf(&out, "%s\t%s %s\tcount=%d\tfirst_seen=%s\tlast_sent=%s\n",
+			fp, state.Check, state.Metric, state.Count,
+			state.FirstSeen.Format(time.RFC3339), state.LastSent.Format(time.RFC3339))
+	}
+	return out.String()
+}
+
+// Silence mutes a single alert fingerprint (as reported by Status) for the
+// given duration, for the socket's "silence <fingerprint> <duration>"
+// command, independent of the blanket SilenceUntil.
+func (i *Inspeqtor) Silence(fingerprint string, duration time.Duration) {
+	i.SilenceFingerprint(fingerprint, time.Now().Add(duration))
+}
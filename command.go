@@ -0,0 +1,64 @@
+package inspeqtor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HandleCommand routes a single line read off the Unix management socket to
+// the fingerprint/history state this series added, and returns the text
+// reply to write back to the connection. It's split out from acceptCommand
+// (which owns the actual net.Conn accept/read loop, outside this source
+// fragment) so that dispatch table can add a case for "status", "silence",
+// and "history" that calls this without needing to know anything about
+// Status/Silence/RecentHistory itself:
+//
+//	case "status", "silence", "history":
+//		fmt.Fprint(conn, i.HandleCommand(line))
+//
+// Supported commands:
+//
+//	status                       active alert fingerprints (see Status)
+//	silence <fingerprint> <dur>  mute one fingerprint for dur (see Silence)
+//	history [count]              last count snapshots, default 10 (see RecentHistory)
+func (i *Inspeqtor) HandleCommand(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	switch fields[0] {
+	case "status":
+		return i.Status()
+	case "silence":
+		if len(fields) != 3 {
+			return "usage: silence <fingerprint> <duration>\n"
+		}
+		d, err := time.ParseDuration(fields[2])
+		if err != nil {
+			return fmt.Sprintf("invalid duration %q: %s\n", fields[2], err.Error())
+		}
+		i.Silence(fields[1], d)
+		return fmt.Sprintf("silenced %s for %s\n", fields[1], d)
+	case "history":
+		count := 10
+		if len(fields) == 2 {
+			if n, err := strconv.Atoi(fields[1]); err == nil {
+				count = n
+			}
+		}
+		snapshots, err := i.RecentHistory(count)
+		if err != nil {
+			return fmt.Sprintf("error: %s\n", err.Error())
+		}
+		var out strings.Builder
+		for _, snap := range snapshots {
+			fmt.Fprintf(&out, "%s\t%+v\n", snap.Timestamp.Format(time.RFC3339), snap.HostMetrics)
+		}
+		return out.String()
+	default:
+		return fmt.Sprintf("unknown command %q\n", fields[0])
+	}
+}
@@ -0,0 +1,277 @@
+/*
+Package history persists the metrics and alerts collected on each scan cycle
+to a gob-encoded, rotating set of files on disk, and keeps an in-memory ring
+buffer of the most recent samples per (checkable, metric) pair for rule
+evaluation that needs windowed state (e.g. "sustained for N cycles") to
+survive a restart.
+
+Layout under a store's directory:
+
+	current     the most recently written Snapshot
+	prev-0      the snapshot before that
+	prev-1      ...
+	prev-N      the oldest snapshot retained
+
+Retention (the number of prev-* files kept) is configurable; the oldest
+snapshot is dropped once the store is full.
+*/
+package history
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// AlertRecord is the part of a Snapshot describing one currently-active
+// alert, keyed the same way as an alert fingerprint.
+type AlertRecord struct {
+	Check       string
+	Metric      string
+	Status      string
+	Fingerprint string
+}
+
+// Snapshot is what gets written to disk once per scan cycle.
+type Snapshot struct {
+	Timestamp      time.Time
+	HostMetrics    map[string]float64
+	ServiceMetrics map[string]map[string]float64
+	Alerts         []AlertRecord
+}
+
+// Sample is a single (timestamp, value) pair for one metric, as returned by
+// Store.Recent.
+type Sample struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+const currentFile = "current"
+
+// Store manages the on-disk rotation and an in-memory ring buffer of recent
+// samples. It is safe for concurrent use.
+type Store struct {
+	dir       string
+	retention int
+	ringSize  int
+
+	mu   sync.Mutex
+	ring map[string][]Sample
+}
+
+// NewStore prepares a Store rooted at dir, keeping up to retention prev-*
+// snapshots in addition to "current". ringSize bounds how many in-memory
+// samples are kept per metric before older ones must be faulted back in
+// from disk.
+func NewStore(dir string, retention int) *Store {
+	return &Store{
+		dir:       dir,
+		retention: retention,
+		ringSize:  120,
+		ring:      map[string][]Sample{},
+	}
+}
+
+func (s *Store) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+func (s *Store) prevPath(index int) string {
+	return s.path(fmt.Sprintf("prev-%d", index))
+}
+
+// Write rotates the existing snapshots on disk, writes snap as the new
+// "current", and folds its metrics into the in-memory ring buffer.
+func (s *Store) Write(snap *Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	if err := s.rotate(); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(snap); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(s.path(currentFile), buf.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	s.remember(snap)
+	return nil
+}
+
+// rotate shifts every existing prev-N to prev-(N+1), dropping the oldest,
+// then moves "current" into the now-empty "prev-0" slot.
+func (s *Store) rotate() error {
+	if s.retention <= 0 {
+		return nil
+	}
+
+	oldest := s.prevPath(s.retention - 1)
+	if exists(oldest) {
+		if err := os.Remove(oldest); err != nil {
+			return err
+		}
+	}
+	for i := s.retention - 2; i >= 0; i-- {
+		from := s.prevPath(i)
+		if !exists(from) {
+			continue
+		}
+		if err := os.Rename(from, s.prevPath(i+1)); err != nil {
+			return err
+		}
+	}
+
+	current := s.path(currentFile)
+	if exists(current) {
+		return os.Rename(current, s.prevPath(0))
+	}
+	return nil
+}
+
+// Load reads the most recent ("current") snapshot, if any. It returns
+// (nil, nil) when the store has never been written to.
+func (s *Store) Load() (*Snapshot, error) {
+	return s.loadFile(s.path(currentFile))
+}
+
+func (s *Store) loadFile(path string) (*Snapshot, error) {
+	if !exists(path) {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// Recent reloads the last count snapshots from disk, newest first, for
+// serving over the Unix socket's "history" command.
+func (s *Store) Recent(count int) ([]*Snapshot, error) {
+	if count <= 0 {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshots := make([]*Snapshot, 0, count)
+	current, err := s.loadFile(s.path(currentFile))
+	if err != nil {
+		return nil, err
+	}
+	if current != nil {
+		snapshots = append(snapshots, current)
+	}
+	for i := 0; i < s.retention && len(snapshots) < count; i++ {
+		snap, err := s.loadFile(s.prevPath(i))
+		if err != nil {
+			return nil, err
+		}
+		if snap == nil {
+			break
+		}
+		snapshots = append(snapshots, snap)
+	}
+	return snapshots, nil
+}
+
+func key(checkable, metric string) string {
+	return checkable + "|" + metric
+}
+
+// remember folds a freshly-written snapshot's metrics into the ring buffer.
+func (s *Store) remember(snap *Snapshot) {
+	for metric, value := range snap.HostMetrics {
+		s.push(key("localhost", metric), Sample{snap.Timestamp, value})
+	}
+	for checkable, metrics := range snap.ServiceMetrics {
+		for metric, value := range metrics {
+			s.push(key(checkable, metric), Sample{snap.Timestamp, value})
+		}
+	}
+}
+
+func (s *Store) push(k string, sample Sample) {
+	samples := append(s.ring[k], sample)
+	if len(samples) > s.ringSize {
+		samples = samples[len(samples)-s.ringSize:]
+	}
+	s.ring[k] = samples
+}
+
+// RecentValues returns up to count samples for (checkable, metric), newest
+// last. It is served entirely from the in-memory ring buffer unless that
+// buffer doesn't have enough history yet, in which case older snapshots are
+// lazily faulted in from disk and merged in.
+func (s *Store) RecentValues(checkable, metric string, count int) ([]Sample, error) {
+	s.mu.Lock()
+	k := key(checkable, metric)
+	ringSamples := append([]Sample(nil), s.ring[k]...)
+	s.mu.Unlock()
+
+	if len(ringSamples) >= count {
+		return ringSamples[len(ringSamples)-count:], nil
+	}
+
+	// The ring doesn't have enough history yet (e.g. it was just rebuilt by
+	// a restart or Reload), so fault in older cycles from disk. Skip any
+	// disk timestamp already covered by the ring to avoid double-counting
+	// the cycles that are in both places.
+	disk, err := s.Recent(s.retention + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keyed by UnixNano rather than the time.Time itself: a ring sample's
+	// Timestamp came from time.Now() and carries a monotonic clock reading,
+	// while the same instant read back from disk has been stripped of it by
+	// gob, so the two compare unequal under == (and as map keys) even though
+	// they name the same cycle.
+	seen := make(map[int64]bool, len(ringSamples))
+	for _, sample := range ringSamples {
+		seen[sample.Timestamp.UnixNano()] = true
+	}
+
+	var older []Sample
+	for i := len(disk) - 1; i >= 0; i-- {
+		var value float64
+		var found bool
+		if checkable == "localhost" {
+			value, found = disk[i].HostMetrics[metric]
+		} else if m, ok := disk[i].ServiceMetrics[checkable]; ok {
+			value, found = m[metric]
+		}
+		if found && !seen[disk[i].Timestamp.UnixNano()] {
+			older = append(older, Sample{disk[i].Timestamp, value})
+		}
+	}
+
+	merged := append(older, ringSamples...)
+	if len(merged) > count {
+		merged = merged[len(merged)-count:]
+	}
+	return merged, nil
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,193 @@
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func tempStore(t *testing.T, retention int) (*Store, func()) {
+	dir, err := ioutil.TempDir("", "inspeqtor-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewStore(dir, retention), func() { os.RemoveAll(dir) }
+}
+
+func TestWriteAndLoad(t *testing.T) {
+	store, cleanup := tempStore(t, 3)
+	defer cleanup()
+
+	snap := &Snapshot{
+		Timestamp:   time.Unix(1000, 0),
+		HostMetrics: map[string]float64{"load1": 0.5},
+	}
+	if err := store.Write(snap); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded == nil || loaded.HostMetrics["load1"] != 0.5 {
+		t.Fatalf("Expected to load back the written snapshot, got %+v", loaded)
+	}
+}
+
+func TestLoadWithNothingWrittenYet(t *testing.T) {
+	store, cleanup := tempStore(t, 3)
+	defer cleanup()
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded != nil {
+		t.Fatalf("Expected no snapshot yet, got %+v", loaded)
+	}
+}
+
+func TestRotationDropsOldestBeyondRetention(t *testing.T) {
+	store, cleanup := tempStore(t, 2)
+	defer cleanup()
+
+	for i := 0; i < 5; i++ {
+		snap := &Snapshot{
+			Timestamp:   time.Unix(int64(1000+i), 0),
+			HostMetrics: map[string]float64{"load1": float64(i)},
+		}
+		if err := store.Write(snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshots, err := store.Recent(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// "current" plus 2 retained prev-* snapshots
+	if len(snapshots) != 3 {
+		t.Fatalf("Expected 3 retained snapshots, got %d", len(snapshots))
+	}
+	if snapshots[0].HostMetrics["load1"] != 4 {
+		t.Fatalf("Expected newest snapshot first, got %+v", snapshots[0])
+	}
+}
+
+func TestRecentValuesFromRingBuffer(t *testing.T) {
+	store, cleanup := tempStore(t, 3)
+	defer cleanup()
+
+	for i := 0; i < 5; i++ {
+		snap := &Snapshot{
+			Timestamp:   time.Unix(int64(1000+i), 0),
+			HostMetrics: map[string]float64{"load1": float64(i)},
+		}
+		if err := store.Write(snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	samples, err := store.RecentValues("localhost", "load1", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 3 {
+		t.Fatalf("Expected 3 samples, got %d", len(samples))
+	}
+	if samples[len(samples)-1].Value != 4 {
+		t.Fatalf("Expected the most recent sample last, got %+v", samples)
+	}
+}
+
+func TestRecentValuesDoesNotDuplicateCyclesAlreadyInRing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inspeqtor-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewStore(dir, 3)
+	for i := 0; i < 2; i++ {
+		snap := &Snapshot{
+			Timestamp:   time.Unix(int64(1000+i), 0),
+			HostMetrics: map[string]float64{"load1": float64(i)},
+		}
+		if err := store.Write(snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	samples, err := store.RecentValues("localhost", "load1", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("Expected exactly 2 unique samples, got %d: %+v", len(samples), samples)
+	}
+}
+
+func TestRecentValuesDoesNotDuplicateAcrossGobRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inspeqtor-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Use real time.Now() timestamps, as production does: they carry a
+	// monotonic reading that gob strips on disk round-trip, which is what
+	// previously made the ring-vs-disk de-dup miss its own cycles.
+	store := NewStore(dir, 3)
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		snap := &Snapshot{
+			Timestamp:   now.Add(time.Duration(i) * time.Second),
+			HostMetrics: map[string]float64{"load1": float64(i)},
+		}
+		if err := store.Write(snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	samples, err := store.RecentValues("localhost", "load1", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("Expected exactly 2 unique samples, got %d: %+v", len(samples), samples)
+	}
+}
+
+func TestRecentValuesFaultsInFromDiskAfterRingReset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inspeqtor-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewStore(dir, 3)
+	for i := 0; i < 3; i++ {
+		snap := &Snapshot{
+			Timestamp:      time.Unix(int64(1000+i), 0),
+			ServiceMetrics: map[string]map[string]float64{"mysql": {"rss": float64(i)}},
+		}
+		if err := store.Write(snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Simulate a restart: a fresh Store has no ring buffer yet.
+	restarted := NewStore(dir, 3)
+	samples, err := restarted.RecentValues("mysql", "rss", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) == 0 {
+		t.Fatal("Expected samples faulted in from disk after restart")
+	}
+	if samples[len(samples)-1].Value != 2 {
+		t.Fatalf("Expected newest value last, got %+v", samples)
+	}
+}
@@ -1,7 +1,11 @@
 package inspeqtor
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"inspeqtor/history"
+	"inspeqtor/notify"
 	"inspeqtor/services"
 	"inspeqtor/util"
 	"net"
@@ -12,6 +16,10 @@ import (
 	"time"
 )
 
+// defaultHistoryDir is where scan cycles are persisted, matching the rest of
+// Inspeqtor's state under /var/lib/inspeqtor.
+const defaultHistoryDir = "/var/lib/inspeqtor/history"
+
 const (
 	VERSION = "1.0.0"
 )
@@ -27,6 +35,30 @@ type Inspeqtor struct {
 	GlobalConfig    *ConfigFile
 	Socket          net.Listener
 	SilenceUntil    time.Time
+
+	// configMu guards Host, Services, GlobalConfig, History and LastHistory:
+	// Reload's Parse() call swaps all five out from under a scanService
+	// that may be mid-cycle reading them with no synchronization otherwise.
+	// scanSystem holds the read lock for an entire trust/verify/recordHistory
+	// cycle rather than re-acquiring it per field, so that cycle always sees
+	// one consistent snapshot instead of a reload tearing it half-updated.
+	configMu sync.RWMutex
+
+	// AlertStates tracks the fingerprint of every currently-failing rule so
+	// repeat notifications can be deduplicated; see alert_state.go.
+	AlertStates             map[string]*alertState
+	alertStatesMutex        sync.Mutex
+	FingerprintSilences     map[string]time.Time
+	fingerprintSilenceMutex sync.Mutex
+
+	// History persists each cycle's metrics and alerts to disk; see
+	// history.Store and recordHistory. HistoryDir defaults to
+	// defaultHistoryDir but can be overridden before calling Parse.
+	History     *history.Store
+	HistoryDir  string
+	LastHistory *history.Snapshot
+
+	cancel context.CancelFunc
 }
 
 func New(dir string, socketpath string) (*Inspeqtor, error) {
@@ -35,62 +67,110 @@ func New(dir string, socketpath string) (*Inspeqtor, error) {
 		StartedAt:    time.Now(),
 		SilenceUntil: time.Now(),
 		Host:         &Host{&Entity{name: "localhost"}},
-		GlobalConfig: &ConfigFile{Defaults, map[string]*AlertRoute{}}}
+		GlobalConfig: &ConfigFile{Defaults, map[string]*AlertRoute{}},
+		HistoryDir:   defaultHistoryDir}
 	return i, nil
 }
 
 var (
 	Term os.Signal = syscall.SIGTERM
+	Hup  os.Signal = syscall.SIGHUP
 
 	SignalHandlers = map[os.Signal]func(*Inspeqtor){
 		Term:         exit,
 		os.Interrupt: exit,
+		Hup:          reload,
 	}
 	Name      string = "Inspeqtor"
 	Licensing string = "Licensed under the GNU Public License 3.0"
 )
 
-func (i *Inspeqtor) Start() {
+// Start brings up the socket acceptor and scan loop as supervised Services
+// and blocks until ctx is cancelled (typically by SIGTERM/SIGINT, see
+// handleSignals). A failed Reload is only logged and does not stop it. It
+// only returns once every Service has
+// drained: the socket is closed, the scan loop has finished any
+// in-progress scanSystem, and no further notifications are in flight.
+func (i *Inspeqtor) Start(ctx context.Context) error {
 	err := i.openSocket(i.SocketPath)
 	if err != nil {
 		util.Warn("Could not create Unix socket: %s", err.Error())
-		exit(i)
+		return err
 	}
 
+	ctx, cancel := context.WithCancel(ctx)
+	i.cancel = cancel
+	go handleSignals(ctx, i)
+
+	// net.Listener.Accept only ever returns once the listener is closed, so
+	// commandService can't notice ctx being cancelled on its own: close the
+	// socket as soon as the root context goes away to unblock it.
 	go func() {
-		for {
-			i.acceptCommand()
+		<-ctx.Done()
+		if i.Socket != nil {
+			if err := i.Socket.Close(); err != nil {
+				util.Warn(err.Error())
+			}
 		}
 	}()
 
-	go i.runLoop()
+	supervise(ctx, &commandService{i}, &scanService{i})
 
-	// This method never returns
-	handleSignals(i)
+	util.Info(Name + " exiting")
+	return nil
+}
+
+// Reload re-parses the global and service configuration in place, without
+// dropping the socket or losing in-memory alert state. It is wired up to
+// SIGHUP by handleSignals.
+func (i *Inspeqtor) Reload() error {
+	util.Info("Reloading configuration")
+	return i.Parse()
 }
 
+// Parse (re-)reads the global and service configuration and, on success,
+// swaps Host/Services/GlobalConfig/History/LastHistory in behind configMu
+// in one atomic step, so a scanSystem cycle running concurrently (Parse is
+// called again on every SIGHUP via Reload, without stopping the scan loop)
+// always sees either the old state or the new one, never a mix.
 func (i *Inspeqtor) Parse() error {
-	i.ServiceManagers = services.Detect()
+	serviceManagers := services.Detect()
 
 	config, err := ParseGlobal(i.RootDir)
 	if err != nil {
 		return err
 	}
 	util.DebugDebug("Global config: %+v", config)
-	i.GlobalConfig = config
 
-	host, services, err := ParseInq(i.GlobalConfig, i.RootDir+"/conf.d")
+	host, svcs, err := ParseInq(config, i.RootDir+"/conf.d")
 	if err != nil {
 		return err
 	}
-	i.Host = host
-	i.Services = services
 
 	util.DebugDebug("Config: %+v", config)
 	util.DebugDebug("Host: %+v", host)
-	for _, val := range services {
+	for _, val := range svcs {
 		util.DebugDebug("Service: %+v", val)
 	}
+
+	historyStore := history.NewStore(i.HistoryDir, int(config.Top.HistoryRetention))
+	last, err := historyStore.Load()
+	if err != nil {
+		util.Warn("Could not load history: %s", err.Error())
+	} else if last != nil {
+		util.Debug("Loaded last snapshot from %s", last.Timestamp)
+	}
+
+	i.configMu.Lock()
+	i.ServiceManagers = serviceManagers
+	i.GlobalConfig = config
+	i.Host = host
+	i.Services = svcs
+	i.History = historyStore
+	if last != nil {
+		i.LastHistory = last
+	}
+	i.configMu.Unlock()
 	return nil
 }
 
@@ -113,60 +193,186 @@ func HandleSignal(sig os.Signal, handler func(*Inspeqtor)) {
 	SignalHandlers[sig] = handler
 }
 
-func handleSignals(i *Inspeqtor) {
-	signals := make(chan os.Signal)
-	for k, _ := range SignalHandlers {
+// handleSignals dispatches OS signals to the registered SignalHandlers until
+// the root context is cancelled. Unlike the old implementation it no longer
+// runs the process's only non-daemon goroutine: Start's supervise call is
+// what actually keeps the process alive, so handleSignals selects on ctx
+// alongside the signal channel and returns as soon as ctx is Done, instead
+// of ranging over signals forever (which is never closed).
+func handleSignals(ctx context.Context, i *Inspeqtor) {
+	signals := make(chan os.Signal, 1)
+	for k := range SignalHandlers {
 		signal.Notify(signals, k)
 	}
+	defer signal.Stop(signals)
 
 	for {
-		sig := <-signals
-		util.Debug("Received signal %d", sig)
-		funk := SignalHandlers[sig]
-		funk(i)
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			util.Debug("Received signal %d", sig)
+			funk := SignalHandlers[sig]
+			funk(i)
+		}
 	}
 }
 
+// exit cancels the root context instead of calling os.Exit, so Start can
+// drain every Service (closing the socket, letting an in-progress
+// scanSystem finish) before the process actually stops.
 func exit(i *Inspeqtor) {
-	util.Info(Name + " exiting")
-	if i.Socket != nil {
-		err := i.Socket.Close()
-		if err != nil {
-			util.Warn(err.Error())
+	util.Info(Name + " shutting down")
+	if i.cancel != nil {
+		i.cancel()
+	}
+}
+
+func reload(i *Inspeqtor) {
+	if err := i.Reload(); err != nil {
+		util.Warn("Reload failed: %s", err.Error())
+	}
+}
+
+// commandService adapts the existing Unix socket acceptor to the Service
+// interface so it can be supervised alongside the scan loop.
+type commandService struct {
+	i *Inspeqtor
+}
+
+func (c *commandService) Serve(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+			c.i.acceptCommand()
 		}
 	}
-	os.Exit(0)
 }
 
-// this method never returns.
-//
-// since we can't test this method in an automated fashion, it should
-// contain as little logic as possible.
-func (i *Inspeqtor) runLoop() {
+// scanService runs the periodic trust/verify cycle. It replaces the old
+// never-returning runLoop goroutine: its Serve method returns as soon as
+// ctx is cancelled, rather than looping forever, so it can be exercised in
+// tests and cleanly drained on shutdown.
+type scanService struct {
+	i *Inspeqtor
+}
+
+func (s *scanService) Serve(ctx context.Context) error {
 	util.DebugDebug("Resolving services")
-	for _, svc := range i.Services {
-		svc.Resolve(i.ServiceManagers)
+	s.i.configMu.RLock()
+	for _, svc := range s.i.Services {
+		svc.Resolve(s.i.ServiceManagers)
 	}
+	s.i.configMu.RUnlock()
 
-	i.scanSystem()
+	s.i.scanSystem()
 
 	for {
 		select {
-		case <-time.After(time.Duration(i.GlobalConfig.Top.CycleTime) * time.Second):
-			i.scanSystem()
+		case <-ctx.Done():
+			return nil
+		case <-time.After(s.i.cycleTime()):
+			s.i.scanSystem()
 		}
 	}
 }
 
+func (i *Inspeqtor) cycleTime() time.Duration {
+	i.configMu.RLock()
+	defer i.configMu.RUnlock()
+	return time.Duration(i.GlobalConfig.Top.CycleTime) * time.Second
+}
+
 func (i *Inspeqtor) silenced() bool {
 	return time.Now().Before(i.SilenceUntil)
 }
 
+// scanSystem runs one trust/verify/recordHistory cycle under a single read
+// lock on configMu, so a concurrent Reload can't swap Host/Services/
+// GlobalConfig out from under it mid-cycle (see configMu's doc comment).
 func (i *Inspeqtor) scanSystem() {
+	i.configMu.RLock()
+	defer i.configMu.RUnlock()
+
 	// "Trust, but verify"
 	// https://en.wikipedia.org/wiki/Trust%2C_but_verify
 	i.trust()
 	i.verify()
+	i.recordHistory()
+}
+
+// MetricSource is implemented by a Checkable that can report the raw metric
+// values behind its rules, so recordHistory can snapshot them. It is
+// optional: a Checkable that doesn't implement it is simply omitted from
+// the snapshot.
+type MetricSource interface {
+	Metrics() map[string]float64
+}
+
+// recordHistory persists this cycle's metrics and currently-active alerts
+// to i.History, so rules needing windowed state (and external tooling
+// polling the socket's "history" command) survive a restart. Failures are logged, not fatal: a
+// full disk shouldn't take monitoring down.
+func (i *Inspeqtor) recordHistory() {
+	if i.History == nil {
+		return
+	}
+
+	snap := &history.Snapshot{
+		Timestamp:      time.Now(),
+		ServiceMetrics: map[string]map[string]float64{},
+	}
+	if ms, ok := interface{}(i.Host).(MetricSource); ok {
+		snap.HostMetrics = ms.Metrics()
+	}
+	for _, svc := range i.Services {
+		if ms, ok := svc.(MetricSource); ok {
+			snap.ServiceMetrics[svc.Name()] = ms.Metrics()
+		}
+	}
+	for fp, state := range i.ActiveAlerts() {
+		snap.Alerts = append(snap.Alerts, history.AlertRecord{
+			Check:       state.Check,
+			Metric:      state.Metric,
+			Status:      state.LastStatus,
+			Fingerprint: fp,
+		})
+	}
+
+	if err := i.History.Write(snap); err != nil {
+		util.Warn("Could not write history snapshot: %s", err.Error())
+	}
+}
+
+// RecentHistory returns the last count snapshots, newest first, for the
+// Unix socket's "history" command.
+func (i *Inspeqtor) RecentHistory(count int) ([]*history.Snapshot, error) {
+	i.configMu.RLock()
+	defer i.configMu.RUnlock()
+
+	if i.History == nil {
+		return nil, nil
+	}
+	return i.History.Recent(count)
+}
+
+// WindowedValues returns up to count historical samples for (checkable,
+// metric), oldest first, so a rule needing windowed state (e.g. "sustained
+// for N cycles") can rebuild it from before a restart instead of starting
+// cold. It's backed by i.History's in-memory ring, which transparently
+// faults in older cycles from the on-disk snapshots i.LastHistory also came
+// from once the ring itself doesn't have enough samples yet -- exactly the
+// case right after a restart.
+func (i *Inspeqtor) WindowedValues(checkable, metric string, count int) ([]history.Sample, error) {
+	i.configMu.RLock()
+	defer i.configMu.RUnlock()
+
+	if i.History == nil {
+		return nil, nil
+	}
+	return i.History.RecentValues(checkable, metric, count)
 }
 
 func (i *Inspeqtor) trust() {
@@ -206,48 +412,112 @@ func (i *Inspeqtor) verify() {
 		for _, svc := range i.Services {
 			svc.Verify()
 		}
+		i.fireRuleEvents()
 	}
 }
 
-/*
-func (i *Inspeqtor) handleProcessEvent(etype EventType, svc Checkable) {
-	if i.silenced() {
-		util.Debug("SILENCED %s %s", etype, svc.Name())
-		return
+// fireRuleEvents walks every rule on the host and each service after a scan
+// cycle's Verify() calls have updated their status, and dispatches any that
+// are currently alerting. This is the live replacement for the
+// handleProcessEvent/handleRuleEvent pair this project used to carry around
+// commented out: fireRuleEvent is a plain method on Inspeqtor, so unlike
+// that pair it's actually reachable from verify() and can be exercised in
+// tests.
+func (i *Inspeqtor) fireRuleEvents() {
+	for _, rule := range i.Host.Rules() {
+		i.fireRuleEvent(i.Host, rule)
 	}
-
-	util.Warn("%s %s", etype, svc.Name())
-
-	evt := Event{etype, svc, nil}
-	err := svc.Trigger(&evt)
-	if err != nil {
-		util.Warn("%s", err)
+	for _, svc := range i.Services {
+		for _, rule := range svc.Rules() {
+			i.fireRuleEvent(svc, rule)
+		}
 	}
 }
 
-func (i *Inspeqtor) handleRuleEvent(etype EventType, check Checkable, rule *Rule) {
+// fireRuleEvent dispatches rule through every Action it carries (one per
+// "then alert NAME" clause resolved at config-load time), gating each
+// through the per-fingerprint dedup and silence state in alert_state.go so
+// a sustained failure only renotifies once per RenotifyInterval, and firing
+// RuleRecovered instead of RuleFailed the first cycle a fingerprint clears.
+//
+// Rule.Actions are pre-resolved per-route Actions, so the AlertRoute.Name
+// that produced a given one isn't available here; each Action's position in
+// Rule.Actions is used instead as a stable per-cycle substitute for the
+// fingerprint's route component.
+//
+// An Action built from a "url"/"email"/"gmail" route (see notify_action.go's
+// wrapping of Actions["alert"]) comes back as a *notifierAction; it's
+// dispatched through TriggerFor directly rather than via the generic
+// Action.Trigger(*Event) path, since check/rule are already in scope here
+// and make for a more useful notification than Event's fields alone would.
+func (i *Inspeqtor) fireRuleEvent(check Checkable, rule *Rule) {
 	if i.silenced() {
-		util.Debug("SILENCED %s %s", etype, check.Name())
+		util.Debug("SILENCED %s", check.Name())
 		return
 	}
 
-	util.Warn("%s %s", etype, check.Name())
+	status := fmt.Sprintf("%v", rule.Status)
+
+	for idx, action := range rule.Actions {
+		routeKey := fmt.Sprintf("action-%d", idx)
+		fp := Fingerprint(check.Name(), rule, routeKey)
+		if i.fingerprintSilenced(fp) {
+			continue
+		}
+
+		send, recovered := i.Notify(check, rule, routeKey, status)
+		if !send {
+			continue
+		}
 
-	evt := Event{etype, check, rule}
-	for _, action := range rule.Actions {
-		err := action.Trigger(&evt)
+		var err error
+		if na, ok := action.(*notifierAction); ok {
+			detail := fmt.Sprintf("%s %s %v (status=%s)", rule.MetricName, rule.Op, rule.Threshold, status)
+			if recovered {
+				detail = fmt.Sprintf("RECOVERED: %s", detail)
+			}
+			err = na.TriggerFor(check.Name(), detail)
+		} else {
+			etype := RuleFailed
+			if recovered {
+				etype = RuleRecovered
+			}
+			evt := Event{etype, check, rule}
+			err = action.Trigger(&evt)
+		}
 		if err != nil {
 			util.Warn("%s", err)
 		}
 	}
 }
-*/
+
+// TestNotifications fires a test alert down every configured route, for the
+// "inspeqtor -test" CLI flag. A route built from the "url" channel (or from
+// the legacy "email"/"gmail" channels, which are also re-expressed as a
+// notify.Notifier; see buildNotifier) is sent through its Notifier directly;
+// anything else falls back to the legacy Actions["alert"] path.
 func (i *Inspeqtor) TestNotifications() {
+	i.configMu.RLock()
+	defer i.configMu.RUnlock()
+
 	for _, route := range i.GlobalConfig.AlertRoutes {
 		nm := route.Name
 		if nm == "" {
 			nm = "default"
 		}
+
+		if route.Notifier != nil {
+			util.Info("Triggering notification for %s/%s", route.Channel, nm)
+			err := route.Notifier.Send(&notify.Message{
+				Title: fmt.Sprintf("[TEST] %s", i.Host.Rules()[0].MetricName),
+				Body:  "This is a test notification from Inspeqtor",
+			})
+			if err != nil {
+				util.Warn("Error firing %s/%s route: %s", route.Channel, nm, err.Error())
+			}
+			continue
+		}
+
 		util.Info("Creating notification for %s/%s", route.Channel, nm)
 		notifier, err := Actions["alert"](i.Host, route)
 		if err != nil {
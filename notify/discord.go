@@ -0,0 +1,44 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+func init() {
+	Register("discord", newDiscord)
+}
+
+// discord posts to a Discord webhook: discord://token@channel
+type discord struct {
+	webhookURL string
+}
+
+func newDiscord(u *url.URL) (Notifier, error) {
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("discord url requires a token: discord://token@channel")
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("discord url requires a channel id: discord://token@channel")
+	}
+	return &discord{fmt.Sprintf("https://discord.com/api/webhooks/%s/%s", u.Host, u.User.Username())}, nil
+}
+
+func (d *discord) Send(msg *Message) error {
+	payload, err := json.Marshal(map[string]string{"content": msg.Title + "\n" + msg.Body})
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(d.webhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("discord webhook returned %s", resp.Status)
+	}
+	return nil
+}
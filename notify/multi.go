@@ -0,0 +1,34 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// multi fans a single Send out to every underlying Notifier, so a route
+// configured with several target URLs delivers to all of them.
+type multi struct {
+	notifiers []Notifier
+}
+
+// Fanout combines several notifiers into one that sends to all of them,
+// collecting any failures rather than stopping at the first one.
+func Fanout(notifiers ...Notifier) Notifier {
+	if len(notifiers) == 1 {
+		return notifiers[0]
+	}
+	return &multi{notifiers}
+}
+
+func (m *multi) Send(msg *Message) error {
+	var errs bytes.Buffer
+	for _, n := range m.notifiers {
+		if err := n.Send(msg); err != nil {
+			fmt.Fprintf(&errs, "%s; ", err.Error())
+		}
+	}
+	if errs.Len() > 0 {
+		return fmt.Errorf("%s", errs.String())
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func init() {
+	Register("pushover", newPushover)
+}
+
+// pushover delivers via pushover.net: pushover://apiToken@userKey/?priority=1
+type pushover struct {
+	apiToken string
+	userKey  string
+	priority string
+}
+
+func newPushover(u *url.URL) (Notifier, error) {
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("pushover url requires an api token: pushover://apiToken@userKey/")
+	}
+	userKey := strings.TrimPrefix(u.Path, "/")
+	if u.Host != "" {
+		userKey = u.Host
+	}
+	if userKey == "" {
+		return nil, fmt.Errorf("pushover url requires a user key: pushover://apiToken@userKey/")
+	}
+	priority := u.Query().Get("priority")
+	if priority == "" {
+		priority = "0"
+	}
+	return &pushover{u.User.Username(), userKey, priority}, nil
+}
+
+func (p *pushover) Send(msg *Message) error {
+	resp, err := http.PostForm("https://api.pushover.net/1/messages.json", url.Values{
+		"token":    {p.apiToken},
+		"user":     {p.userKey},
+		"title":    {msg.Title},
+		"message":  {msg.Body},
+		"priority": {p.priority},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("pushover returned %s", resp.Status)
+	}
+	return nil
+}
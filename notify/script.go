@@ -0,0 +1,25 @@
+package notify
+
+import (
+	"net/url"
+	"os/exec"
+)
+
+func init() {
+	Register("script", newScript)
+}
+
+// script runs a local executable, passing the message title and body as
+// arguments. Useful as a last-resort integration point for anything not
+// covered by a dedicated channel.
+type script struct {
+	path string
+}
+
+func newScript(u *url.URL) (Notifier, error) {
+	return &script{u.Path}, nil
+}
+
+func (s *script) Send(msg *Message) error {
+	return exec.Command(s.path, msg.Title, msg.Body).Run()
+}
@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func init() {
+	Register("slack", newSlack)
+}
+
+// slack posts to a single Incoming Webhook:
+// slack://botname@token-a/token-b/token-c
+type slack struct {
+	botname string
+	hookURL string
+}
+
+func newSlack(u *url.URL) (Notifier, error) {
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("slack url requires a bot name: slack://botname@token-a/token-b/token-c")
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if u.Host != "" {
+		parts = append([]string{u.Host}, parts...)
+	}
+	if len(parts) != 3 || parts[0] == "" {
+		return nil, fmt.Errorf("slack url requires three webhook path segments: slack://botname@token-a/token-b/token-c")
+	}
+	hookURL := fmt.Sprintf("https://hooks.slack.com/services/%s", strings.Join(parts, "/"))
+	return &slack{u.User.Username(), hookURL}, nil
+}
+
+func (s *slack) Send(msg *Message) error {
+	payload, err := json.Marshal(map[string]string{
+		"username": s.botname,
+		"text":     msg.Title + "\n" + msg.Body,
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(s.hookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("slack webhook returned %s", resp.Status)
+	}
+	return nil
+}
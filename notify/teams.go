@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func init() {
+	Register("teams", newTeams)
+}
+
+// teams posts to a Microsoft Teams connector webhook:
+// teams://token-a/token-b/token-c
+type teams struct {
+	hookURL string
+}
+
+func newTeams(u *url.URL) (Notifier, error) {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if u.Host != "" {
+		parts = append([]string{u.Host}, parts...)
+	}
+	if len(parts) != 3 || parts[0] == "" {
+		return nil, fmt.Errorf("teams url requires three webhook path segments: teams://token-a/token-b/token-c")
+	}
+	hookURL := fmt.Sprintf("https://outlook.office.com/webhook/%s/IncomingWebhook/%s/%s", parts[0], parts[1], parts[2])
+	return &teams{hookURL}, nil
+}
+
+func (t *teams) Send(msg *Message) error {
+	payload, err := json.Marshal(map[string]string{
+		"title": msg.Title,
+		"text":  msg.Body,
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(t.hookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("teams webhook returned %s", resp.Status)
+	}
+	return nil
+}
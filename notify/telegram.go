@@ -0,0 +1,47 @@
+package notify
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func init() {
+	Register("telegram", newTelegram)
+}
+
+// telegram sends via the Bot API: telegram://token@telegram?channels=chat1,chat2
+type telegram struct {
+	token    string
+	channels []string
+}
+
+func newTelegram(u *url.URL) (Notifier, error) {
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("telegram url requires a bot token: telegram://token@telegram?channels=...")
+	}
+	channels := u.Query().Get("channels")
+	if channels == "" {
+		return nil, fmt.Errorf("telegram url requires at least one channel: ?channels=...")
+	}
+	return &telegram{u.User.Username(), strings.Split(channels, ",")}, nil
+}
+
+func (t *telegram) Send(msg *Message) error {
+	for _, channel := range t.channels {
+		endpoint := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", t.token)
+		resp, err := http.PostForm(endpoint, url.Values{
+			"chat_id": {channel},
+			"text":    {msg.Title + "\n" + msg.Body},
+		})
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("telegram send to %s returned %s", channel, resp.Status)
+		}
+	}
+	return nil
+}
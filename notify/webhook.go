@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+func init() {
+	Register("webhook", newWebhook)
+}
+
+// webhook POSTs the Message as JSON to an arbitrary HTTP(S) endpoint. It is
+// the generic escape hatch for services with no dedicated implementation.
+type webhook struct {
+	url string
+}
+
+func newWebhook(u *url.URL) (Notifier, error) {
+	target := *u
+	target.Scheme = "http"
+	q := target.Query()
+	if q.Get("https") == "1" {
+		target.Scheme = "https"
+	}
+	// "https" is our own scheme-selection toggle, not part of the
+	// destination's API; strip it so it isn't forwarded to the remote
+	// webhook along with the caller's own query params.
+	q.Del("https")
+	target.RawQuery = q.Encode()
+	return &webhook{target.String()}, nil
+}
+
+func (w *webhook) Send(msg *Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned %s", w.url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package inspeqtor
+
+import (
+	"fmt"
+	"inspeqtor/notify"
+)
+
+// init wraps the legacy "alert" Action factory so any AlertRoute built from
+// the new "url"/"email"/"gmail" channels (see buildNotifier in
+// global_parser.go) is actually used once a rule fires. Without this,
+// rule.Actions entries built via Actions["alert"] never touch route.Notifier
+// at all: the legacy factory predates the notify package and has no idea
+// it exists. Wrapping the factory here, rather than threading route.Notifier
+// through fireRuleEvent by hand, means every Rule.Actions entry built from
+// Actions["alert"] for such a route gets the new dispatch path for free.
+// (TestNotifications sends through route.Notifier itself and only calls
+// Actions["alert"] for routes without one.)
+func init() {
+	legacy := Actions["alert"]
+	Actions["alert"] = func(host *Host, route *AlertRoute) (Action, error) {
+		if route.Notifier != nil {
+			return &notifierAction{route}, nil
+		}
+		return legacy(host, route)
+	}
+}
+
+// notifierAction adapts an AlertRoute's notify.Notifier to the Action
+// interface, so it can sit in Rule.Actions like any other entry produced by
+// Actions["alert"].
+type notifierAction struct {
+	route *AlertRoute
+}
+
+// Trigger satisfies the Action interface for callers that only have an
+// *Event to hand, such as a legacy caller of Actions["alert"]'s result.
+// It ignores evt and sends a generic message without the triggering
+// check/rule; fireRuleEvent, which has that information directly, calls
+// TriggerFor instead, which produces a more useful message.
+func (a *notifierAction) Trigger(evt *Event) error {
+	return a.TriggerFor("", "")
+}
+
+// TriggerFor sends a notification for this route with checkName/detail
+// folded into the message, for callers that already have that context
+// on hand instead of reconstructing it from an *Event.
+func (a *notifierAction) TriggerFor(checkName, detail string) error {
+	title := fmt.Sprintf("[%s] alert", a.route.Name)
+	if checkName != "" {
+		title = fmt.Sprintf("%s: %s", title, checkName)
+	}
+	body := detail
+	if body == "" {
+		body = "An Inspeqtor rule fired; see the host for details."
+	}
+	return a.route.Notifier.Send(&notify.Message{Title: title, Body: body})
+}
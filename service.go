@@ -0,0 +1,69 @@
+package inspeqtor
+
+import (
+	"context"
+	"fmt"
+	"inspeqtor/util"
+	"sync"
+	"time"
+)
+
+// Service is anything Start supervises: the socket acceptor, the scan loop,
+// and (eventually) individual notifier workers all implement it. Serve
+// should return promptly once ctx is cancelled.
+type Service interface {
+	Serve(ctx context.Context) error
+}
+
+const maxBackoff = 30 * time.Second
+
+// supervise runs every Service concurrently and blocks until all of them
+// have returned. A Service that panics or returns a non-nil error while ctx
+// is still live is restarted after a backoff instead of taking the whole
+// process down with it.
+func supervise(ctx context.Context, services ...Service) {
+	var wg sync.WaitGroup
+	wg.Add(len(services))
+	for _, svc := range services {
+		go func(svc Service) {
+			defer wg.Done()
+			superviseOne(ctx, svc)
+		}(svc)
+	}
+	wg.Wait()
+}
+
+func superviseOne(ctx context.Context, svc Service) {
+	backoff := 500 * time.Millisecond
+	for {
+		err := runGuarded(ctx, svc)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			return
+		}
+
+		util.Warn("Service error, restarting in %s: %s", backoff, err.Error())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// runGuarded recovers a panicking Service so one bad cycle can be restarted
+// by superviseOne rather than crashing every other supervised Service too.
+func runGuarded(ctx context.Context, svc Service) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return svc.Serve(ctx)
+}
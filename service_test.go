@@ -0,0 +1,85 @@
+package inspeqtor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingService struct {
+	calls   int32
+	fail    int32
+	panics  int32
+	stopped chan struct{}
+}
+
+func (s *countingService) Serve(ctx context.Context) error {
+	n := atomic.AddInt32(&s.calls, 1)
+	if s.stopped != nil {
+		defer func() { s.stopped <- struct{}{} }()
+	}
+	if n <= atomic.LoadInt32(&s.panics) {
+		panic("boom")
+	}
+	if n <= atomic.LoadInt32(&s.fail) {
+		return errors.New("transient failure")
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func TestRunGuardedRecoversPanic(t *testing.T) {
+	svc := &countingService{panics: 1}
+	err := runGuarded(context.Background(), svc)
+	if err == nil {
+		t.Fatal("Expected a panic to surface as an error")
+	}
+}
+
+func TestSuperviseOneRestartsAfterError(t *testing.T) {
+	svc := &countingService{fail: 2, stopped: make(chan struct{}, 3)}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		superviseOne(ctx, svc)
+		close(done)
+	}()
+
+	<-svc.stopped
+	<-svc.stopped
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("superviseOne did not return after ctx was cancelled")
+	}
+
+	if atomic.LoadInt32(&svc.calls) < 2 {
+		t.Fatalf("Expected Serve to be called at least twice (restarted after a transient failure), got %d", svc.calls)
+	}
+}
+
+func TestSuperviseWaitsForEveryService(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a := &countingService{}
+	b := &countingService{}
+
+	done := make(chan struct{})
+	go func() {
+		supervise(ctx, a, b)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("supervise did not return once every Service stopped")
+	}
+}